@@ -0,0 +1,160 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"strconv"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+)
+
+// ECS is the subset of the Alicloud ECS API the bastion actuator needs to create and inspect compute
+// instances, security groups and their rules. It is implemented by ecsClient below and can be faked for
+// unit tests, mirroring the VPC and RAM clients in this package.
+type ECS interface {
+	GetInstances(instanceName string) (*ecs.DescribeInstancesResponse, error)
+	CreateInstances(instanceName, securityGroupID, imageID, vSwitchID, zoneID, instanceType, privateIP, ramRoleName, systemDiskCategory, systemDiskSize, internetChargeType string, userData []byte) (*ecs.CreateInstanceResponse, error)
+	AllocatePublicIp(instanceID string) (*ecs.AllocatePublicIpAddressResponse, error)
+
+	GetInstanceType(cores int, zoneID string) (*ecs.DescribeAvailableResourceResponse, error)
+	GetInstanceTypeAvailability(instanceType, zoneID string) (bool, error)
+
+	GetSecurityGroup(securityGroupName string) (*ecs.DescribeSecurityGroupsResponse, error)
+	CreateSecurityGroups(vpcID, securityGroupName string) (*ecs.CreateSecurityGroupResponse, error)
+	DescribeSecurityGroupAttribute(request *ecs.DescribeSecurityGroupAttributeRequest) (*ecs.DescribeSecurityGroupAttributeResponse, error)
+
+	CreateIngressRule(request *ecs.AuthorizeSecurityGroupRequest) error
+	RevokeIngressRule(request *ecs.RevokeSecurityGroupRequest) error
+	CreateEgressRule(request *ecs.AuthorizeSecurityGroupEgressRequest) error
+	RevokeEgressRule(request *ecs.RevokeSecurityGroupEgressRequest) error
+}
+
+type ecsClient struct {
+	EcsClient *ecs.Client
+}
+
+// NewECSClient creates a new ECS client for the given region and credentials.
+func NewECSClient(region, accessKeyID, accessKeySecret string) (ECS, error) {
+	ec, err := ecs.NewClientWithAccessKey(region, accessKeyID, accessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ecsClient{EcsClient: ec}, nil
+}
+
+func (c *ecsClient) GetInstances(instanceName string) (*ecs.DescribeInstancesResponse, error) {
+	request := ecs.CreateDescribeInstancesRequest()
+	request.InstanceName = instanceName
+	return c.EcsClient.DescribeInstances(request)
+}
+
+// CreateInstances creates the bastion compute instance with privateIP as the primary NIC's private IP
+// address and ramRoleName as its RAM role, in addition to the image/disk/network settings Reconcile
+// otherwise derives.
+func (c *ecsClient) CreateInstances(instanceName, securityGroupID, imageID, vSwitchID, zoneID, instanceType, privateIP, ramRoleName, systemDiskCategory, systemDiskSize, internetChargeType string, userData []byte) (*ecs.CreateInstanceResponse, error) {
+	request := ecs.CreateCreateInstanceRequest()
+	request.InstanceName = instanceName
+	request.SecurityGroupId = securityGroupID
+	request.ImageId = imageID
+	request.VSwitchId = vSwitchID
+	request.ZoneId = zoneID
+	request.InstanceType = instanceType
+	request.PrivateIpAddress = privateIP
+	request.RamRoleName = ramRoleName
+	request.SystemDiskCategory = systemDiskCategory
+	request.SystemDiskSize = systemDiskSize
+	request.InternetChargeType = internetChargeType
+	request.UserData = string(userData)
+
+	return c.EcsClient.CreateInstance(request)
+}
+
+func (c *ecsClient) AllocatePublicIp(instanceID string) (*ecs.AllocatePublicIpAddressResponse, error) {
+	request := ecs.CreateAllocatePublicIpAddressRequest()
+	request.InstanceId = instanceID
+	return c.EcsClient.AllocatePublicIpAddress(request)
+}
+
+func (c *ecsClient) GetInstanceType(cores int, zoneID string) (*ecs.DescribeAvailableResourceResponse, error) {
+	request := ecs.CreateDescribeAvailableResourceRequest()
+	request.ZoneId = zoneID
+	request.DestinationResource = "InstanceType"
+	request.Cores = strconv.Itoa(cores)
+	return c.EcsClient.DescribeAvailableResource(request)
+}
+
+// GetInstanceTypeAvailability reports whether instanceType can be launched in zoneID, so that a configured
+// opt.MachineType can be validated before CreateInstances is attempted with it.
+func (c *ecsClient) GetInstanceTypeAvailability(instanceType, zoneID string) (bool, error) {
+	request := ecs.CreateDescribeAvailableResourceRequest()
+	request.ZoneId = zoneID
+	request.DestinationResource = "InstanceType"
+	request.InstanceType = instanceType
+
+	response, err := c.EcsClient.DescribeAvailableResource(request)
+	if err != nil {
+		return false, err
+	}
+
+	for _, zone := range response.AvailableZones.AvailableZone {
+		for _, resource := range zone.AvailableResources.AvailableResource {
+			for _, supported := range resource.SupportedResources.SupportedResource {
+				if supported.Value == instanceType && supported.Status == "Available" {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (c *ecsClient) GetSecurityGroup(securityGroupName string) (*ecs.DescribeSecurityGroupsResponse, error) {
+	request := ecs.CreateDescribeSecurityGroupsRequest()
+	request.SecurityGroupName = securityGroupName
+	return c.EcsClient.DescribeSecurityGroups(request)
+}
+
+func (c *ecsClient) CreateSecurityGroups(vpcID, securityGroupName string) (*ecs.CreateSecurityGroupResponse, error) {
+	request := ecs.CreateCreateSecurityGroupRequest()
+	request.VpcId = vpcID
+	request.SecurityGroupName = securityGroupName
+	return c.EcsClient.CreateSecurityGroup(request)
+}
+
+func (c *ecsClient) DescribeSecurityGroupAttribute(request *ecs.DescribeSecurityGroupAttributeRequest) (*ecs.DescribeSecurityGroupAttributeResponse, error) {
+	return c.EcsClient.DescribeSecurityGroupAttribute(request)
+}
+
+func (c *ecsClient) CreateIngressRule(request *ecs.AuthorizeSecurityGroupRequest) error {
+	_, err := c.EcsClient.AuthorizeSecurityGroup(request)
+	return err
+}
+
+func (c *ecsClient) RevokeIngressRule(request *ecs.RevokeSecurityGroupRequest) error {
+	_, err := c.EcsClient.RevokeSecurityGroup(request)
+	return err
+}
+
+func (c *ecsClient) CreateEgressRule(request *ecs.AuthorizeSecurityGroupEgressRequest) error {
+	_, err := c.EcsClient.AuthorizeSecurityGroupEgress(request)
+	return err
+}
+
+func (c *ecsClient) RevokeEgressRule(request *ecs.RevokeSecurityGroupEgressRequest) error {
+	_, err := c.EcsClient.RevokeSecurityGroupEgress(request)
+	return err
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+// ClientFactory creates the per-region, per-credential Alicloud clients the bastion actuator needs. It
+// exists so the actuator can be tested against fakes instead of having to go through real SDK clients.
+type ClientFactory interface {
+	NewECSClient(region, accessKeyID, accessKeySecret string) (ECS, error)
+	NewVPCClient(region, accessKeyID, accessKeySecret string) (VPC, error)
+	NewRAMClient(region, accessKeyID, accessKeySecret string) (RAM, error)
+}
+
+type clientFactory struct{}
+
+// NewClientFactory returns the ClientFactory backed by the real Alicloud SDK clients.
+func NewClientFactory() ClientFactory {
+	return &clientFactory{}
+}
+
+func (f *clientFactory) NewECSClient(region, accessKeyID, accessKeySecret string) (ECS, error) {
+	return NewECSClient(region, accessKeyID, accessKeySecret)
+}
+
+func (f *clientFactory) NewVPCClient(region, accessKeyID, accessKeySecret string) (VPC, error) {
+	return NewVPCClient(region, accessKeyID, accessKeySecret)
+}
+
+func (f *clientFactory) NewRAMClient(region, accessKeyID, accessKeySecret string) (RAM, error) {
+	return NewRAMClient(region, accessKeyID, accessKeySecret)
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ram"
+)
+
+// RAM is the subset of the Alicloud RAM API this extension needs to manage scoped roles, e.g. for bastion
+// instances. It is implemented by ramClient below and can be faked for unit tests, mirroring the ECS and
+// VPC clients in this package.
+type RAM interface {
+	GetPolicy(policyName string) (*ram.GetPolicyResponse, error)
+	CreatePolicy(policyName, policyDocument string) (*ram.CreatePolicyResponse, error)
+	DeletePolicy(policyName string) error
+
+	GetRole(roleName string) (*ram.GetRoleResponse, error)
+	CreateRole(roleName, assumeRolePolicyDocument string) (*ram.CreateRoleResponse, error)
+	DeleteRole(roleName string) error
+
+	AttachPolicyToRole(policyName, roleName string) error
+	DetachPolicyFromRole(policyName, roleName string) error
+	IsPolicyAttachedToRole(policyName, roleName string) (bool, error)
+}
+
+type ramClient struct {
+	RamClient *ram.Client
+}
+
+// NewRAMClient creates a new RAM client for the given region and credentials.
+func NewRAMClient(region, accessKeyID, accessKeySecret string) (RAM, error) {
+	rc, err := ram.NewClientWithAccessKey(region, accessKeyID, accessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ramClient{RamClient: rc}, nil
+}
+
+func (c *ramClient) GetPolicy(policyName string) (*ram.GetPolicyResponse, error) {
+	request := ram.CreateGetPolicyRequest()
+	request.PolicyName = policyName
+	request.PolicyType = "Custom"
+	return c.RamClient.GetPolicy(request)
+}
+
+func (c *ramClient) CreatePolicy(policyName, policyDocument string) (*ram.CreatePolicyResponse, error) {
+	request := ram.CreateCreatePolicyRequest()
+	request.PolicyName = policyName
+	request.PolicyDocument = policyDocument
+	request.Description = "Scoped read-only policy for an Alicloud bastion instance, managed by gardener-extension-provider-alicloud."
+	return c.RamClient.CreatePolicy(request)
+}
+
+func (c *ramClient) DeletePolicy(policyName string) error {
+	request := ram.CreateDeletePolicyRequest()
+	request.PolicyName = policyName
+	_, err := c.RamClient.DeletePolicy(request)
+	return err
+}
+
+func (c *ramClient) GetRole(roleName string) (*ram.GetRoleResponse, error) {
+	request := ram.CreateGetRoleRequest()
+	request.RoleName = roleName
+	return c.RamClient.GetRole(request)
+}
+
+func (c *ramClient) CreateRole(roleName, assumeRolePolicyDocument string) (*ram.CreateRoleResponse, error) {
+	request := ram.CreateCreateRoleRequest()
+	request.RoleName = roleName
+	request.AssumeRolePolicyDocument = assumeRolePolicyDocument
+	request.Description = "Role assumed by an Alicloud bastion instance, managed by gardener-extension-provider-alicloud."
+	return c.RamClient.CreateRole(request)
+}
+
+func (c *ramClient) DeleteRole(roleName string) error {
+	request := ram.CreateDeleteRoleRequest()
+	request.RoleName = roleName
+	_, err := c.RamClient.DeleteRole(request)
+	return err
+}
+
+func (c *ramClient) AttachPolicyToRole(policyName, roleName string) error {
+	request := ram.CreateAttachPolicyToRoleRequest()
+	request.PolicyName = policyName
+	request.PolicyType = "Custom"
+	request.RoleName = roleName
+	_, err := c.RamClient.AttachPolicyToRole(request)
+	return err
+}
+
+func (c *ramClient) DetachPolicyFromRole(policyName, roleName string) error {
+	request := ram.CreateDetachPolicyFromRoleRequest()
+	request.PolicyName = policyName
+	request.PolicyType = "Custom"
+	request.RoleName = roleName
+	_, err := c.RamClient.DetachPolicyFromRole(request)
+	return err
+}
+
+// IsPolicyAttachedToRole reports whether policyName is already attached to roleName, so that callers can
+// make AttachPolicyToRole idempotent without relying on it tolerating being called twice.
+func (c *ramClient) IsPolicyAttachedToRole(policyName, roleName string) (bool, error) {
+	request := ram.CreateListPoliciesForRoleRequest()
+	request.RoleName = roleName
+
+	response, err := c.RamClient.ListPoliciesForRole(request)
+	if err != nil {
+		return false, err
+	}
+
+	for _, policy := range response.Policies.Policy {
+		if policy.PolicyName == policyName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+)
+
+// VPCInfo and VSwitchInfo are the VPC/vSwitch details GetVPCInfoByName and GetVSwitchesInfoByID resolve
+// from the underlying DescribeVpcs/DescribeVSwitches responses, so callers do not have to reach into the
+// SDK's response shape themselves.
+type VPCInfo struct {
+	VPCID     string
+	VSwitchID string
+}
+
+type VSwitchInfo struct {
+	VSwitchID string
+	ZoneID    string
+}
+
+// VPC is the subset of the Alicloud VPC API this extension needs, both to resolve a shoot's VPC/vSwitch by
+// name and to reserve a private IP for a bastion instance. It is implemented by vpcClient below and can be
+// faked for unit tests, mirroring the ECS and RAM clients in this package.
+type VPC interface {
+	GetVPCInfoByName(vpcName string) (*VPCInfo, error)
+	GetVSwitchesInfoByID(vSwitchID string) (*VSwitchInfo, error)
+
+	CreateNetworkInterface(vSwitchID string) (*vpc.CreateNetworkInterfaceResponse, error)
+	DeleteNetworkInterface(networkInterfaceID string) error
+}
+
+type vpcClient struct {
+	VpcClient *vpc.Client
+}
+
+// NewVPCClient creates a new VPC client for the given region and credentials.
+func NewVPCClient(region, accessKeyID, accessKeySecret string) (VPC, error) {
+	vc, err := vpc.NewClientWithAccessKey(region, accessKeyID, accessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vpcClient{VpcClient: vc}, nil
+}
+
+// GetVPCInfoByName resolves the VPC ID and the ID of one of its vSwitches by the VPC's name. It is used as
+// a fallback for shoots whose Infrastructure resource has not recorded a provider status yet.
+func (c *vpcClient) GetVPCInfoByName(vpcName string) (*VPCInfo, error) {
+	request := vpc.CreateDescribeVpcsRequest()
+	request.VpcName = vpcName
+
+	response, err := c.VpcClient.DescribeVpcs(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Vpcs.Vpc) == 0 {
+		return nil, fmt.Errorf("no vpc found with name %s", vpcName)
+	}
+
+	foundVpc := response.Vpcs.Vpc[0]
+	if len(foundVpc.VSwitchIds.VSwitchId) == 0 {
+		return nil, fmt.Errorf("vpc %s has no vswitches", vpcName)
+	}
+
+	return &VPCInfo{VPCID: foundVpc.VpcId, VSwitchID: foundVpc.VSwitchIds.VSwitchId[0]}, nil
+}
+
+// GetVSwitchesInfoByID resolves the zone a vSwitch belongs to.
+func (c *vpcClient) GetVSwitchesInfoByID(vSwitchID string) (*VSwitchInfo, error) {
+	request := vpc.CreateDescribeVSwitchesRequest()
+	request.VSwitchId = vSwitchID
+
+	response, err := c.VpcClient.DescribeVSwitches(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.VSwitches.VSwitch) == 0 {
+		return nil, fmt.Errorf("no vswitch found with id %s", vSwitchID)
+	}
+
+	return &VSwitchInfo{VSwitchID: vSwitchID, ZoneID: response.VSwitches.VSwitch[0].ZoneId}, nil
+}
+
+// CreateNetworkInterface creates an ENI in vSwitchID with no other purpose than having the VSwitch hand out
+// a free private IP; see private_ip.go for why the ENI itself is torn down again right away.
+func (c *vpcClient) CreateNetworkInterface(vSwitchID string) (*vpc.CreateNetworkInterfaceResponse, error) {
+	request := vpc.CreateCreateNetworkInterfaceRequest()
+	request.VSwitchId = vSwitchID
+
+	return c.VpcClient.CreateNetworkInterface(request)
+}
+
+// DeleteNetworkInterface deletes the ENI identified by networkInterfaceID.
+func (c *vpcClient) DeleteNetworkInterface(networkInterfaceID string) error {
+	request := vpc.CreateDeleteNetworkInterfaceRequest()
+	request.NetworkInterfaceId = networkInterfaceID
+
+	_, err := c.VpcClient.DeleteNetworkInterface(request)
+	return err
+}
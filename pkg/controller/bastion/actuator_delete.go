@@ -0,0 +1,60 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"context"
+
+	"github.com/gardener/gardener-extension-provider-alicloud/pkg/alicloud"
+
+	"github.com/gardener/gardener/extensions/pkg/controller"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Delete releases the resources Reconcile reserved for the bastion instance outside of the instance
+// itself, so that they do not outlive the Bastion resource.
+func (a *actuator) Delete(ctx context.Context, bastion *extensionsv1alpha1.Bastion, cluster *controller.Cluster) error {
+	logger := a.logger.WithValues("bastion", client.ObjectKeyFromObject(bastion), "operation", "delete")
+
+	opt, err := DetermineOptions(bastion, cluster)
+	if err != nil {
+		return err
+	}
+
+	credentials, err := alicloud.ReadCredentialsFromSecretRef(ctx, a.client, &opt.SecretReference)
+	if err != nil {
+		return err
+	}
+
+	if err := releasePrivateIP(ctx, a.client, bastion); err != nil {
+		return err
+	}
+
+	logger.Info("released bastion private ip reservation")
+
+	aliCloudRAMClient, err := a.newClientFactory.NewRAMClient(opt.Region, credentials.AccessKeyID, credentials.AccessKeySecret)
+	if err != nil {
+		return err
+	}
+
+	if err := releaseRAMRole(aliCloudRAMClient, opt); err != nil {
+		return err
+	}
+
+	logger.Info("released bastion ram role", "role", ramRoleName(opt))
+
+	return nil
+}
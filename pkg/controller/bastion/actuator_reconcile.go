@@ -70,56 +70,49 @@ func (a *actuator) Reconcile(ctx context.Context, bastion *extensionsv1alpha1.Ba
 		return err
 	}
 
-	imageID, err := getImageID(cluster, opt)
+	aliCloudRAMClient, err := a.newClientFactory.NewRAMClient(opt.Region, credentials.AccessKeyID, credentials.AccessKeySecret)
 	if err != nil {
 		return err
 	}
 
-	vpcInfo, err := aliCloudVPCClient.GetVPCInfoByName(opt.VpcName)
+	ramRoleName, err := ensureRAMRole(aliCloudRAMClient, opt, logger)
 	if err != nil {
 		return err
 	}
 
-	vSwitchInfo, err := aliCloudVPCClient.GetVSwitchesInfoByID(vpcInfo.VSwitchID)
-	if err != nil {
-		return err
-	}
-
-	var instanceTypeId string
-	for cores := 1; cores <= 2; cores++ {
-		instanceType, err := aliCloudECSClient.GetInstanceType(cores, vSwitchInfo.ZoneID)
+	imageID := opt.ImageID
+	if imageID == "" {
+		imageID, err = getImageID(cluster, opt)
 		if err != nil {
 			return err
 		}
+	}
 
-		if instanceType == nil ||
-			len(instanceType.AvailableZones.AvailableZone) == 0 ||
-			len(instanceType.AvailableZones.AvailableZone[0].AvailableResources.AvailableResource) == 0 ||
-			len(instanceType.AvailableZones.AvailableZone[0].AvailableResources.AvailableResource[0].SupportedResources.SupportedResource) == 0 ||
-			instanceType.AvailableZones.AvailableZone[0].AvailableResources.AvailableResource[0].SupportedResources.SupportedResource[0].Status != "Available" {
-			continue
-		}
-
-		instanceTypeId = instanceType.AvailableZones.AvailableZone[0].AvailableResources.AvailableResource[0].SupportedResources.SupportedResource[0].Value
-		break
+	vpcID, vSwitchID, zoneID, err := getSubnet(ctx, a.client, aliCloudVPCClient, bastion, cluster, opt)
+	if err != nil {
+		return err
 	}
 
-	if instanceTypeId == "" {
-		if len(cluster.CloudProfile.Spec.MachineTypes) == 0 {
-			return errors.New("failed to determine instanceTypeId from cloud profile as fallback. Machine types missing from cloud profile")
-		}
+	instanceTypeId, err := determineInstanceType(aliCloudECSClient, cluster, opt, zoneID)
+	if err != nil {
+		return err
+	}
 
-		instanceTypeId = cluster.CloudProfile.Spec.MachineTypes[0].Name
-		logger.Info("falling back to first machine type of cloud profile as bastion instance type id", "instance type", cluster.CloudProfile.Spec.MachineTypes[0].Name)
+	securityGroupID, err := ensureSecurityGroup(aliCloudECSClient, opt.SecurityGroupName, vpcID, logger)
+	if err != nil {
+		return err
 	}
 
-	securityGroupID, err := ensureSecurityGroup(aliCloudECSClient, opt.SecurityGroupName, vpcInfo.VPCID, logger)
+	privateIP, err := ensurePrivateIP(ctx, a.client, aliCloudVPCClient, bastion, vSwitchID)
 	if err != nil {
 		return err
 	}
 
-	instanceID, err := ensureComputeInstance(aliCloudECSClient, logger, opt, securityGroupID, imageID, vpcInfo.VSwitchID, vSwitchInfo.ZoneID, instanceTypeId)
+	instanceID, err := ensureComputeInstance(aliCloudECSClient, logger, opt, securityGroupID, imageID, vSwitchID, zoneID, instanceTypeId, privateIP, ramRoleName)
 	if err != nil {
+		if releaseErr := releasePrivateIP(ctx, a.client, bastion); releaseErr != nil {
+			return fmt.Errorf("failed to create bastion instance (%w) and failed to release the reserved private ip again (%w)", err, releaseErr)
+		}
 		return err
 	}
 
@@ -145,7 +138,7 @@ func (a *actuator) Reconcile(ctx context.Context, bastion *extensionsv1alpha1.Ba
 		return err
 	}
 
-	endpoints, err := getInstanceEndpoints(aliCloudECSClient, opt, publicIP.IpAddress)
+	endpoints, err := getInstanceEndpoints(aliCloudECSClient, opt, privateIP, publicIP.IpAddress)
 	if err != nil {
 		return err
 	}
@@ -191,7 +184,7 @@ func addressToIngress(dnsName *string, ipAddress *string) *corev1.LoadBalancerIn
 	return ingress
 }
 
-func getInstanceEndpoints(c aliclient.ECS, opt *Options, ip string) (*bastionEndpoints, error) {
+func getInstanceEndpoints(c aliclient.ECS, opt *Options, privateIP, publicIP string) (*bastionEndpoints, error) {
 	response, err := c.GetInstances(opt.BastionInstanceName)
 	if err != nil {
 		return nil, err
@@ -205,22 +198,22 @@ func getInstanceEndpoints(c aliclient.ECS, opt *Options, ip string) (*bastionEnd
 		return nil, fmt.Errorf("compute instance not ready yet")
 	}
 
+	// the private IP was already reserved and assigned to the instance before it was created (see
+	// ensurePrivateIP), so unlike the public IP it does not need to be read back from the instance
 	endpoints := &bastionEndpoints{}
-	instance := response.Instances.Instance[0]
-	internalIP := instance.VpcAttributes.PrivateIpAddress.IpAddress[0]
 
-	if ingress := addressToIngress(nil, &internalIP); ingress != nil {
+	if ingress := addressToIngress(nil, &privateIP); ingress != nil {
 		endpoints.private = ingress
 	}
 
-	if ingress := addressToIngress(nil, &ip); ingress != nil {
+	if ingress := addressToIngress(nil, &publicIP); ingress != nil {
 		endpoints.public = ingress
 	}
 
 	return endpoints, nil
 }
 
-func ensureComputeInstance(c aliclient.ECS, logger logr.Logger, opt *Options, securityGroupID, imageID, vSwitchId, zoneID, instanceTypeID string) (string, error) {
+func ensureComputeInstance(c aliclient.ECS, logger logr.Logger, opt *Options, securityGroupID, imageID, vSwitchId, zoneID, instanceTypeID, privateIP, ramRoleName string) (string, error) {
 	response, err := c.GetInstances(opt.BastionInstanceName)
 	if err != nil {
 		return "", err
@@ -232,7 +225,7 @@ func ensureComputeInstance(c aliclient.ECS, logger logr.Logger, opt *Options, se
 
 	logger.Info("creating new bastion compute instance")
 
-	instance, err := c.CreateInstances(opt.BastionInstanceName, securityGroupID, imageID, vSwitchId, zoneID, instanceTypeID, opt.UserData)
+	instance, err := c.CreateInstances(opt.BastionInstanceName, securityGroupID, imageID, vSwitchId, zoneID, instanceTypeID, privateIP, ramRoleName, opt.SystemDiskCategory, opt.SystemDiskSize, opt.InternetChargeType, opt.UserData)
 	if err != nil {
 		return "", err
 	}
@@ -271,7 +264,7 @@ func ensureSecurityGroupRules(c aliclient.ECS, opt *Options, bastion *extensions
 	var wantedIngressRules []*ecs.AuthorizeSecurityGroupRequest
 
 	for _, ingressPermission := range ingressPermissions {
-		wantedIngressRules = append(wantedIngressRules, ingressAllowSSH(securityGroupId, ingressPermission))
+		wantedIngressRules = append(wantedIngressRules, ingressAllowSSH(securityGroupId, opt, ingressPermission))
 	}
 
 	currentIngressRules, err := c.DescribeSecurityGroupAttribute(describeSecurityGroupAttributeRequest(securityGroupId, "ingress"))
@@ -288,7 +281,7 @@ func ensureSecurityGroupRules(c aliclient.ECS, opt *Options, bastion *extensions
 	}
 
 	for _, rule := range rulesToDelete {
-		if err := c.RevokeIngressRule(revokeSecurityGroupRequest(securityGroupId, rule.IpProtocol, rule.PortRange, rule.SourceCidrIp, rule.Ipv6SourceCidrIp)); err != nil {
+		if err := c.RevokeIngressRule(revokeSecurityGroupRequest(securityGroupId, rule)); err != nil {
 			return fmt.Errorf("failed to delete security group rule %s: %w", rule.Description, err)
 		}
 	}
@@ -306,20 +299,10 @@ func ensureSecurityGroupRules(c aliclient.ECS, opt *Options, bastion *extensions
 	// The assumption is that the shoot only has one security group
 	shootSecurityGroupId := shootSecurityGroupResponse.SecurityGroups.SecurityGroup[0].SecurityGroupId
 
-	instanceResponse, err := c.GetInstances(opt.BastionInstanceName)
-	if err != nil {
-		return err
-	}
-
-	if len(instanceResponse.Instances.Instance) == 0 || len(instanceResponse.Instances.Instance[0].VpcAttributes.PrivateIpAddress.IpAddress) == 0 {
-		return errors.New("bastion instance does not have a private ip")
-	}
-
-	privateIP := instanceResponse.Instances.Instance[0].VpcAttributes.PrivateIpAddress.IpAddress[0]
-
 	wantedEgressRules := []*ecs.AuthorizeSecurityGroupEgressRequest{
-		egressAllowSSHToWorker(privateIP, securityGroupId, shootSecurityGroupId),
-		egressDenyAll(securityGroupId)}
+		egressAllowSSHToWorker(securityGroupId, shootSecurityGroupId, opt),
+		egressDenyAllIPv4(securityGroupId, opt),
+		egressDenyAllIPv6(securityGroupId, opt)}
 
 	currentEgressRules, err := c.DescribeSecurityGroupAttribute(describeSecurityGroupAttributeRequest(securityGroupId, "egress"))
 	if err != nil {
@@ -334,7 +317,7 @@ func ensureSecurityGroupRules(c aliclient.ECS, opt *Options, bastion *extensions
 	}
 
 	for _, rule := range rulesToDelete {
-		if err = c.RevokeEgressRule(revokeSecurityGroupEgressRequest(securityGroupId, rule.IpProtocol, rule.PortRange)); err != nil {
+		if err = c.RevokeEgressRule(revokeSecurityGroupEgressRequest(securityGroupId, rule)); err != nil {
 			return fmt.Errorf("failed to delete security egress group rule %s: %w", rule.Description, err)
 		}
 	}
@@ -414,6 +397,18 @@ func ingressRuleEqual(a ecs.AuthorizeSecurityGroupRequest, b ecs.Permission) boo
 		return false
 	}
 
+	if !equality.Semantic.DeepEqual(a.Priority, b.Priority) {
+		return false
+	}
+
+	if !equality.Semantic.DeepEqual(a.NicType, b.NicType) {
+		return false
+	}
+
+	if !equality.Semantic.DeepEqual(a.Policy, b.Policy) {
+		return false
+	}
+
 	return true
 }
 
@@ -468,5 +463,25 @@ func egressRuleEqual(a ecs.AuthorizeSecurityGroupEgressRequest, b ecs.Permission
 		return false
 	}
 
+	if !equality.Semantic.DeepEqual(a.Ipv6SourceCidrIp, b.Ipv6SourceCidrIp) {
+		return false
+	}
+
+	if !equality.Semantic.DeepEqual(a.DestGroupId, b.DestGroupId) {
+		return false
+	}
+
+	if !equality.Semantic.DeepEqual(a.Priority, b.Priority) {
+		return false
+	}
+
+	if !equality.Semantic.DeepEqual(a.NicType, b.NicType) {
+		return false
+	}
+
+	if !equality.Semantic.DeepEqual(a.Policy, b.Policy) {
+		return false
+	}
+
 	return true
 }
@@ -0,0 +1,100 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"context"
+	"fmt"
+
+	aliclient "github.com/gardener/gardener-extension-provider-alicloud/pkg/alicloud/client"
+	apisalicloud "github.com/gardener/gardener-extension-provider-alicloud/pkg/apis/alicloud"
+	apisalicloudhelper "github.com/gardener/gardener-extension-provider-alicloud/pkg/apis/alicloud/helper"
+	apisalicloudinstall "github.com/gardener/gardener-extension-provider-alicloud/pkg/apis/alicloud/install"
+
+	"github.com/gardener/gardener/extensions/pkg/controller"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var infrastructureDecoder runtime.Decoder
+
+func init() {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(apisalicloudinstall.AddToScheme(scheme))
+	infrastructureDecoder = serializer.NewCodecFactory(scheme).UniversalDecoder()
+}
+
+// getInfrastructureStatus reads the InfrastructureStatus recorded on the shoot's Infrastructure resource.
+// It returns nil if the Infrastructure resource or its provider status does not exist yet (e.g. because
+// the infrastructure has never been reconciled), so that callers can fall back to other means of
+// determining the shoot's network.
+func getInfrastructureStatus(ctx context.Context, c client.Client, bastion *extensionsv1alpha1.Bastion, cluster *controller.Cluster) (*apisalicloud.InfrastructureStatus, error) {
+	infrastructure := &extensionsv1alpha1.Infrastructure{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: bastion.Namespace, Name: cluster.Shoot.Name}, infrastructure); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if infrastructure.Status.ProviderStatus == nil {
+		return nil, nil
+	}
+
+	infrastructureStatus := &apisalicloud.InfrastructureStatus{}
+	if _, _, err := infrastructureDecoder.Decode(infrastructure.Status.ProviderStatus.Raw, nil, infrastructureStatus); err != nil {
+		return nil, fmt.Errorf("could not decode infrastructure status of infrastructure '%s': %w", client.ObjectKeyFromObject(infrastructure), err)
+	}
+
+	return infrastructureStatus, nil
+}
+
+// getSubnet resolves the VPC ID, vSwitch ID and zone the bastion instance should be placed in.
+//
+// If the shoot's Infrastructure resource already carries a provider status, the VPC and vSwitch recorded
+// there are used. This also covers BYO-VPC clusters whose VPC name does not follow the shoot's technical
+// ID and would therefore not be found by the name-based lookup below. Only if no status has been recorded
+// yet does it fall back to looking up the VPC by its conventional name and picking one of its vSwitches.
+func getSubnet(ctx context.Context, c client.Client, aliCloudVPCClient aliclient.VPC, bastion *extensionsv1alpha1.Bastion, cluster *controller.Cluster, opt *Options) (vpcID, vSwitchID, zoneID string, err error) {
+	infrastructureStatus, err := getInfrastructureStatus(ctx, c, bastion, cluster)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if infrastructureStatus != nil {
+		vSwitch, err := apisalicloudhelper.FindVSwitchForPurpose(infrastructureStatus.VPC.VSwitches, apisalicloud.PurposeNodes)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		return infrastructureStatus.VPC.ID, vSwitch.ID, vSwitch.Zone, nil
+	}
+
+	vpcInfo, err := aliCloudVPCClient.GetVPCInfoByName(opt.VpcName)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	vSwitchInfo, err := aliCloudVPCClient.GetVSwitchesInfoByID(vpcInfo.VSwitchID)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return vpcInfo.VPCID, vpcInfo.VSwitchID, vSwitchInfo.ZoneID, nil
+}
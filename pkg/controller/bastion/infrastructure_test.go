@@ -0,0 +1,128 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"context"
+	"testing"
+
+	aliclient "github.com/gardener/gardener-extension-provider-alicloud/pkg/alicloud/client"
+
+	"github.com/gardener/gardener/extensions/pkg/controller"
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeSubnetVPC is an in-memory stand-in for the aliclient.VPC methods getSubnet falls back to when no
+// InfrastructureStatus has been recorded yet. It embeds the interface so it satisfies aliclient.VPC without
+// having to know its full method set, and fails the test if the fallback is reached unexpectedly.
+type fakeSubnetVPC struct {
+	aliclient.VPC
+
+	t *testing.T
+}
+
+func (f *fakeSubnetVPC) GetVPCInfoByName(vpcName string) (*aliclient.VPCInfo, error) {
+	f.t.Fatalf("GetVPCInfoByName should not be called once an InfrastructureStatus has been recorded")
+	return nil, nil
+}
+
+func (f *fakeSubnetVPC) GetVSwitchesInfoByID(vSwitchID string) (*aliclient.VSwitchInfo, error) {
+	f.t.Fatalf("GetVSwitchesInfoByID should not be called once an InfrastructureStatus has been recorded")
+	return nil, nil
+}
+
+func TestGetInfrastructureStatusReturnsNilWithoutInfrastructure(t *testing.T) {
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bastion", Namespace: "shoot--foo--bar"},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := extensionsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(bastion).Build()
+	cluster := &controller.Cluster{Shoot: &gardencorev1beta1.Shoot{ObjectMeta: metav1.ObjectMeta{Name: "shoot--foo--bar"}}}
+
+	// No Infrastructure resource has been created for the shoot yet, so getInfrastructureStatus must return
+	// a nil status rather than an error, letting getSubnet fall back to the name-based VPC/vSwitch lookup.
+	status, err := getInfrastructureStatus(context.Background(), c, bastion, cluster)
+	if err != nil {
+		t.Fatalf("getInfrastructureStatus failed: %v", err)
+	}
+
+	if status != nil {
+		t.Errorf("expected a nil status when no Infrastructure resource exists, got %+v", status)
+	}
+}
+
+// TestGetSubnetPrefersInfrastructureStatusOverNameLookup covers the BYO-VPC case: a
+// shoot whose VPC was brought by the user (so its name does not follow the shoot's technical ID) still
+// resolves correctly, because getSubnet reads the VPC/vSwitch straight from the recorded
+// InfrastructureStatus instead of ever falling back to the name-based lookup.
+func TestGetSubnetPrefersInfrastructureStatusOverNameLookup(t *testing.T) {
+	providerStatus := []byte(`{
+		"apiVersion": "alicloud.provider.extensions.gardener.cloud/v1alpha1",
+		"kind": "InfrastructureStatus",
+		"vpc": {
+			"id": "vpc-byo-12345",
+			"vswitches": [
+				{"id": "vsw-byo-67890", "purpose": "nodes", "zone": "eu-central-1a"}
+			]
+		}
+	}`)
+
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bastion", Namespace: "shoot--foo--bar"},
+	}
+	infrastructure := &extensionsv1alpha1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "shoot--foo--bar", Namespace: "shoot--foo--bar"},
+		Status: extensionsv1alpha1.InfrastructureStatus{
+			DefaultStatus: extensionsv1alpha1.DefaultStatus{
+				ProviderStatus: &runtime.RawExtension{Raw: providerStatus},
+			},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := extensionsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(bastion, infrastructure).Build()
+	cluster := &controller.Cluster{Shoot: &gardencorev1beta1.Shoot{ObjectMeta: metav1.ObjectMeta{Name: "shoot--foo--bar"}}}
+	opt := &Options{VpcName: "this-name-does-not-match-the-byo-vpc"}
+
+	vpcID, vSwitchID, zoneID, err := getSubnet(context.Background(), c, &fakeSubnetVPC{t: t}, bastion, cluster, opt)
+	if err != nil {
+		t.Fatalf("getSubnet failed: %v", err)
+	}
+
+	if vpcID != "vpc-byo-12345" {
+		t.Errorf("got vpc id %q, want the id recorded in the InfrastructureStatus", vpcID)
+	}
+
+	if vSwitchID != "vsw-byo-67890" {
+		t.Errorf("got vswitch id %q, want the id recorded in the InfrastructureStatus", vSwitchID)
+	}
+
+	if zoneID != "eu-central-1a" {
+		t.Errorf("got zone %q, want the zone recorded in the InfrastructureStatus", zoneID)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"errors"
+	"fmt"
+
+	aliclient "github.com/gardener/gardener-extension-provider-alicloud/pkg/alicloud/client"
+
+	"github.com/gardener/gardener/extensions/pkg/controller"
+)
+
+// maxFallbackMachineTypeCPU and maxFallbackMachineTypeMemory bound the cloud profile machine type picked
+// as a bastion fallback when no explicit opt.MachineType is configured, so that the bastion does not end
+// up on a large worker type just because it happens to be first in the cloud profile.
+const (
+	maxFallbackMachineTypeCPU    = 2
+	maxFallbackMachineTypeMemory = 4 * 1024 * 1024 * 1024 // 4Gi
+)
+
+// determineInstanceType returns the instance type to use for the bastion instance. If opt.MachineType is
+// set, it is used as-is, after verifying it is actually available in the given zone. Otherwise, the
+// smallest 1-2 vCPU type available in the zone is searched for, falling back to the first cloud profile
+// machine type with at most 2 vCPUs and 4Gi memory if none is found.
+func determineInstanceType(c aliclient.ECS, cluster *controller.Cluster, opt *Options, zoneID string) (string, error) {
+	if opt.MachineType != "" {
+		available, err := c.GetInstanceTypeAvailability(opt.MachineType, zoneID)
+		if err != nil {
+			return "", err
+		}
+
+		if !available {
+			return "", fmt.Errorf("configured bastion machine type %q is not available in zone %q", opt.MachineType, zoneID)
+		}
+
+		return opt.MachineType, nil
+	}
+
+	for cores := 1; cores <= 2; cores++ {
+		instanceType, err := c.GetInstanceType(cores, zoneID)
+		if err != nil {
+			return "", err
+		}
+
+		if instanceType == nil ||
+			len(instanceType.AvailableZones.AvailableZone) == 0 ||
+			len(instanceType.AvailableZones.AvailableZone[0].AvailableResources.AvailableResource) == 0 ||
+			len(instanceType.AvailableZones.AvailableZone[0].AvailableResources.AvailableResource[0].SupportedResources.SupportedResource) == 0 ||
+			instanceType.AvailableZones.AvailableZone[0].AvailableResources.AvailableResource[0].SupportedResources.SupportedResource[0].Status != "Available" {
+			continue
+		}
+
+		return instanceType.AvailableZones.AvailableZone[0].AvailableResources.AvailableResource[0].SupportedResources.SupportedResource[0].Value, nil
+	}
+
+	return fallbackInstanceType(cluster)
+}
+
+func fallbackInstanceType(cluster *controller.Cluster) (string, error) {
+	if len(cluster.CloudProfile.Spec.MachineTypes) == 0 {
+		return "", errors.New("failed to determine instanceTypeId from cloud profile as fallback. Machine types missing from cloud profile")
+	}
+
+	for _, machineType := range cluster.CloudProfile.Spec.MachineTypes {
+		if machineType.CPU.CmpInt64(maxFallbackMachineTypeCPU) <= 0 && machineType.Memory.Value() <= maxFallbackMachineTypeMemory {
+			return machineType.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to determine instanceTypeId from cloud profile as fallback: no machine type with at most %d vCPUs and %dGi memory found", maxFallbackMachineTypeCPU, maxFallbackMachineTypeMemory/(1024*1024*1024))
+}
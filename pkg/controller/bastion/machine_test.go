@@ -0,0 +1,157 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"testing"
+
+	aliclient "github.com/gardener/gardener-extension-provider-alicloud/pkg/alicloud/client"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/gardener/gardener/extensions/pkg/controller"
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// fakeECS is an in-memory stand-in for the two aliclient.ECS methods determineInstanceType uses. It embeds
+// the interface so it satisfies aliclient.ECS without having to implement its full method set.
+type fakeECS struct {
+	aliclient.ECS
+
+	availability    map[string]bool
+	smallestPerZone map[string]string
+}
+
+func (f *fakeECS) GetInstanceTypeAvailability(instanceType, zoneID string) (bool, error) {
+	return f.availability[instanceType+"/"+zoneID], nil
+}
+
+func (f *fakeECS) GetInstanceType(cores int, zoneID string) (*ecs.DescribeAvailableResourceResponse, error) {
+	value, ok := f.smallestPerZone[zoneID]
+	if !ok {
+		return &ecs.DescribeAvailableResourceResponse{}, nil
+	}
+
+	response := &ecs.DescribeAvailableResourceResponse{}
+	response.AvailableZones.AvailableZone = []ecs.AvailableZoneInDescribeAvailableResource{
+		{
+			AvailableResources: ecs.AvailableResourcesInDescribeAvailableResource{
+				AvailableResource: []ecs.AvailableResourceInDescribeAvailableResource{
+					{
+						SupportedResources: ecs.SupportedResourcesInDescribeAvailableResource{
+							SupportedResource: []ecs.SupportedResourceInDescribeAvailableResource{
+								{Status: "Available", Value: value},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return response, nil
+}
+
+func testCloudProfileCluster(machineTypes ...string) *controller.Cluster {
+	var types []gardencorev1beta1.MachineType
+	for _, name := range machineTypes {
+		types = append(types, gardencorev1beta1.MachineType{
+			Name:   name,
+			CPU:    resource.MustParse("2"),
+			Memory: resource.MustParse("4Gi"),
+		})
+	}
+
+	return &controller.Cluster{
+		CloudProfile: &gardencorev1beta1.CloudProfile{
+			Spec: gardencorev1beta1.CloudProfileSpec{MachineTypes: types},
+		},
+	}
+}
+
+func TestDetermineInstanceTypeUsesExplicitOverrideWhenAvailable(t *testing.T) {
+	ecsClient := &fakeECS{availability: map[string]bool{"ecs.t6-c1m2.large/zone-a": true}}
+	opt := &Options{MachineType: "ecs.t6-c1m2.large"}
+
+	got, err := determineInstanceType(ecsClient, testCloudProfileCluster(), opt, "zone-a")
+	if err != nil {
+		t.Fatalf("determineInstanceType failed: %v", err)
+	}
+
+	if got != "ecs.t6-c1m2.large" {
+		t.Errorf("got instance type %q, want the configured override", got)
+	}
+}
+
+func TestDetermineInstanceTypeRejectsOverrideUnavailableInZone(t *testing.T) {
+	ecsClient := &fakeECS{availability: map[string]bool{"ecs.t6-c1m2.large/zone-b": true}}
+	opt := &Options{MachineType: "ecs.t6-c1m2.large"}
+
+	if _, err := determineInstanceType(ecsClient, testCloudProfileCluster(), opt, "zone-a"); err == nil {
+		t.Errorf("expected an error when the configured machine type is unavailable in the zone")
+	}
+}
+
+func TestDetermineInstanceTypeSearchesWhenNoOverrideIsConfigured(t *testing.T) {
+	ecsClient := &fakeECS{smallestPerZone: map[string]string{"zone-a": "ecs.t6-c1m1.small"}}
+	opt := &Options{}
+
+	got, err := determineInstanceType(ecsClient, testCloudProfileCluster(), opt, "zone-a")
+	if err != nil {
+		t.Fatalf("determineInstanceType failed: %v", err)
+	}
+
+	if got != "ecs.t6-c1m1.small" {
+		t.Errorf("got instance type %q, want the type found by GetInstanceType", got)
+	}
+}
+
+func TestFallbackInstanceTypeBoundsToSmallTypes(t *testing.T) {
+	cluster := &controller.Cluster{
+		CloudProfile: &gardencorev1beta1.CloudProfile{
+			Spec: gardencorev1beta1.CloudProfileSpec{
+				MachineTypes: []gardencorev1beta1.MachineType{
+					{Name: "ecs.large", CPU: resource.MustParse("8"), Memory: resource.MustParse("32Gi")},
+					{Name: "ecs.small", CPU: resource.MustParse("2"), Memory: resource.MustParse("4Gi")},
+				},
+			},
+		},
+	}
+
+	got, err := fallbackInstanceType(cluster)
+	if err != nil {
+		t.Fatalf("fallbackInstanceType failed: %v", err)
+	}
+
+	if got != "ecs.small" {
+		t.Errorf("got fallback instance type %q, want the first type within the CPU/memory bound (ecs.small)", got)
+	}
+}
+
+func TestFallbackInstanceTypeErrorsWithoutASuitableType(t *testing.T) {
+	cluster := &controller.Cluster{
+		CloudProfile: &gardencorev1beta1.CloudProfile{
+			Spec: gardencorev1beta1.CloudProfileSpec{
+				MachineTypes: []gardencorev1beta1.MachineType{
+					{Name: "ecs.large", CPU: resource.MustParse("8"), Memory: resource.MustParse("32Gi")},
+				},
+			},
+		},
+	}
+
+	if _, err := fallbackInstanceType(cluster); err == nil {
+		t.Errorf("expected an error when no machine type is within the CPU/memory bound")
+	}
+}
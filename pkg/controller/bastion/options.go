@@ -0,0 +1,142 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"fmt"
+
+	"github.com/gardener/gardener/extensions/pkg/controller"
+	gardencorev1beta1constants "github.com/gardener/gardener/pkg/apis/core/v1beta1/constants"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationMachineType, AnnotationImageID, AnnotationSystemDiskCategory, AnnotationSystemDiskSize and
+	// AnnotationInternetChargeType let a user override the bastion instance defaults for a single Bastion,
+	// as an alternative to setting BastionConfig for the whole extension. A per-Bastion annotation always
+	// takes precedence over BastionConfig, so a user can always override an operator-wide default.
+	AnnotationMachineType        = "alicloud.provider.extensions.gardener.cloud/bastion-machine-type"
+	AnnotationImageID            = "alicloud.provider.extensions.gardener.cloud/bastion-image-id"
+	AnnotationSystemDiskCategory = "alicloud.provider.extensions.gardener.cloud/bastion-system-disk-category"
+	AnnotationSystemDiskSize     = "alicloud.provider.extensions.gardener.cloud/bastion-system-disk-size"
+	AnnotationInternetChargeType = "alicloud.provider.extensions.gardener.cloud/bastion-internet-charge-type"
+)
+
+// BastionConfig is the bastion-specific section of the extension's ControllerConfiguration. It is set once
+// during extension startup and provides operator-wide defaults for the knobs DetermineOptions exposes on
+// Options; a per-Bastion annotation (see above) overrides it. It is nil if the extension was started
+// without a bastion section in its ControllerConfiguration, in which case only the annotations apply.
+var BastionConfig *BastionConfigOptions
+
+// BastionConfigOptions lets an operator default the machine type, image and system disk Reconcile uses for
+// bastion instances, instead of Reconcile always searching for the smallest available type and the shoot's
+// worker image.
+type BastionConfigOptions struct {
+	MachineType        string
+	ImageID            string
+	SystemDiskCategory string
+	SystemDiskSize     string
+	InternetChargeType string
+}
+
+// Options collects the values DetermineOptions derives from a Bastion resource and its Cluster for a
+// single reconciliation, so the rest of the package does not have to repeatedly reach into both.
+type Options struct {
+	SecretReference        corev1.SecretReference
+	Region                 string
+	VpcName                string
+	SecurityGroupName      string
+	ShootSecurityGroupName string
+	BastionInstanceName    string
+	UserData               []byte
+
+	// MachineType, ImageID, SystemDiskCategory, SystemDiskSize and InternetChargeType override the defaults
+	// ensureComputeInstance and determineInstanceType would otherwise pick for the bastion instance. They
+	// are empty unless set via BastionConfig or the corresponding annotation on the Bastion resource.
+	MachineType        string
+	ImageID            string
+	SystemDiskCategory string
+	SystemDiskSize     string
+	InternetChargeType string
+
+	// NicType is the NIC type used for the bastion's security group rules; see nicType in
+	// security_group_rules.go for its default.
+	NicType string
+}
+
+// DetermineOptions derives the Region, names and bastion-instance overrides Reconcile and Delete need from
+// the given Bastion and Cluster.
+func DetermineOptions(bastion *extensionsv1alpha1.Bastion, cluster *controller.Cluster) (*Options, error) {
+	name := cluster.Shoot.Name
+
+	opt := &Options{
+		SecretReference: corev1.SecretReference{
+			Namespace: bastion.Namespace,
+			Name:      gardencorev1beta1constants.SecretNameCloudProvider,
+		},
+		Region:                 cluster.Shoot.Spec.Region,
+		VpcName:                name,
+		SecurityGroupName:      name + "-bsg",
+		ShootSecurityGroupName: name,
+		BastionInstanceName:    fmt.Sprintf("%s-bastion-%s", name, bastion.Name),
+		UserData:               bastion.Spec.UserData,
+	}
+
+	if err := setBastionOverrides(opt, bastion, cluster); err != nil {
+		return nil, err
+	}
+
+	return opt, nil
+}
+
+// setBastionOverrides resolves MachineType, ImageID, SystemDiskCategory, SystemDiskSize and
+// InternetChargeType from the per-Bastion annotations if set, falling back to BastionConfig, and validates
+// the resulting MachineType against the cloud profile so a typo in either source fails fast during
+// reconciliation instead of producing a confusing error from Alicloud once the instance is actually
+// created.
+func setBastionOverrides(opt *Options, bastion *extensionsv1alpha1.Bastion, cluster *controller.Cluster) error {
+	opt.MachineType = bastionOverride(bastion, AnnotationMachineType, func(c *BastionConfigOptions) string { return c.MachineType })
+	opt.ImageID = bastionOverride(bastion, AnnotationImageID, func(c *BastionConfigOptions) string { return c.ImageID })
+	opt.SystemDiskCategory = bastionOverride(bastion, AnnotationSystemDiskCategory, func(c *BastionConfigOptions) string { return c.SystemDiskCategory })
+	opt.SystemDiskSize = bastionOverride(bastion, AnnotationSystemDiskSize, func(c *BastionConfigOptions) string { return c.SystemDiskSize })
+	opt.InternetChargeType = bastionOverride(bastion, AnnotationInternetChargeType, func(c *BastionConfigOptions) string { return c.InternetChargeType })
+
+	if opt.MachineType == "" {
+		return nil
+	}
+
+	for _, machineType := range cluster.CloudProfile.Spec.MachineTypes {
+		if machineType.Name == opt.MachineType {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("configured bastion machine type %q is not present in the cloud profile", opt.MachineType)
+}
+
+// bastionOverride reads value for annotation off the Bastion resource, falling back to the given field of
+// BastionConfig if the annotation is unset and BastionConfig is configured.
+func bastionOverride(bastion *extensionsv1alpha1.Bastion, annotation string, fromConfig func(*BastionConfigOptions) string) string {
+	if value := bastion.Annotations[annotation]; value != "" {
+		return value
+	}
+
+	if BastionConfig != nil {
+		return fromConfig(BastionConfig)
+	}
+
+	return ""
+}
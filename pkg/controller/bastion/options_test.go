@@ -0,0 +1,104 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"testing"
+
+	"github.com/gardener/gardener/extensions/pkg/controller"
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetBastionOverridesAnnotationTakesPrecedenceOverConfig(t *testing.T) {
+	originalConfig := BastionConfig
+	defer func() { BastionConfig = originalConfig }()
+
+	BastionConfig = &BastionConfigOptions{MachineType: "from-config"}
+
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{AnnotationMachineType: "from-annotation"},
+		},
+	}
+
+	opt := &Options{}
+	cluster := testCloudProfileCluster("from-annotation")
+
+	if err := setBastionOverrides(opt, bastion, cluster); err != nil {
+		t.Fatalf("setBastionOverrides failed: %v", err)
+	}
+
+	if opt.MachineType != "from-annotation" {
+		t.Errorf("got machine type %q, want the annotation to take precedence", opt.MachineType)
+	}
+}
+
+func TestSetBastionOverridesFallsBackToConfig(t *testing.T) {
+	originalConfig := BastionConfig
+	defer func() { BastionConfig = originalConfig }()
+
+	BastionConfig = &BastionConfigOptions{MachineType: "from-config"}
+
+	bastion := &extensionsv1alpha1.Bastion{}
+	opt := &Options{}
+	cluster := testCloudProfileCluster("from-config")
+
+	if err := setBastionOverrides(opt, bastion, cluster); err != nil {
+		t.Fatalf("setBastionOverrides failed: %v", err)
+	}
+
+	if opt.MachineType != "from-config" {
+		t.Errorf("got machine type %q, want the BastionConfig default", opt.MachineType)
+	}
+}
+
+func TestSetBastionOverridesRejectsMachineTypeMissingFromCloudProfile(t *testing.T) {
+	originalConfig := BastionConfig
+	defer func() { BastionConfig = originalConfig }()
+	BastionConfig = nil
+
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{AnnotationMachineType: "not-in-profile"},
+		},
+	}
+
+	opt := &Options{}
+	cluster := testCloudProfileCluster("some-other-type")
+
+	if err := setBastionOverrides(opt, bastion, cluster); err == nil {
+		t.Errorf("expected an error for a machine type not present in the cloud profile")
+	}
+}
+
+func TestSetBastionOverridesAllowsNoOverride(t *testing.T) {
+	originalConfig := BastionConfig
+	defer func() { BastionConfig = originalConfig }()
+	BastionConfig = nil
+
+	bastion := &extensionsv1alpha1.Bastion{}
+	opt := &Options{}
+	cluster := &controller.Cluster{CloudProfile: &gardencorev1beta1.CloudProfile{}}
+
+	if err := setBastionOverrides(opt, bastion, cluster); err != nil {
+		t.Errorf("expected no error when no machine type is configured, got: %v", err)
+	}
+
+	if opt.MachineType != "" {
+		t.Errorf("expected machine type to stay empty, got %q", opt.MachineType)
+	}
+}
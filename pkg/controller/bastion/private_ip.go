@@ -0,0 +1,85 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"context"
+	"fmt"
+
+	aliclient "github.com/gardener/gardener-extension-provider-alicloud/pkg/alicloud/client"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// annotationPrivateIP records the private IP reserved for the bastion instance, so that requeues reuse
+	// the same IP instead of discovering a new one on every reconciliation.
+	annotationPrivateIP = "alicloud.provider.extensions.gardener.cloud/bastion-private-ip"
+)
+
+// ensurePrivateIP reserves a private IP address inside vSwitchID for the bastion instance, before the
+// instance itself is created, by briefly creating an ENI and reading the IP the VSwitch assigned to it.
+// The ENI only exists to make the VSwitch hand out a free IP; it is deleted again immediately, because
+// Alicloud will not let CreateInstances hand that same IP to the instance's own primary NIC while another
+// ENI still holds it. This leaves a small window in which a concurrent allocation could take the IP before
+// CreateInstances runs; ensureComputeInstance's caller simply retries reconciliation (discovering a new IP)
+// if that happens. The discovered IP is persisted as an annotation on the Bastion resource so that a
+// requeue after the ENI has already been released reuses it instead of discovering a different one.
+func ensurePrivateIP(ctx context.Context, c client.Client, aliCloudVPCClient aliclient.VPC, bastion *extensionsv1alpha1.Bastion, vSwitchID string) (string, error) {
+	if ip := bastion.Annotations[annotationPrivateIP]; ip != "" {
+		return ip, nil
+	}
+
+	networkInterface, err := aliCloudVPCClient.CreateNetworkInterface(vSwitchID)
+	if err != nil {
+		return "", fmt.Errorf("failed to reserve a private ip for the bastion instance: %w", err)
+	}
+
+	if err := aliCloudVPCClient.DeleteNetworkInterface(networkInterface.NetworkInterfaceId); err != nil {
+		return "", fmt.Errorf("failed to release the eni used to reserve the bastion private ip: %w", err)
+	}
+
+	patch := client.MergeFrom(bastion.DeepCopy())
+	if bastion.Annotations == nil {
+		bastion.Annotations = map[string]string{}
+	}
+	bastion.Annotations[annotationPrivateIP] = networkInterface.PrivateIpAddress
+
+	if err := c.Patch(ctx, bastion, patch); err != nil {
+		return "", fmt.Errorf("failed to persist reserved bastion private ip: %w", err)
+	}
+
+	return networkInterface.PrivateIpAddress, nil
+}
+
+// releasePrivateIP clears the private ip annotation ensurePrivateIP set, if any, so that the next Reconcile
+// discovers a fresh IP instead of retrying one that failed to be claimed. It is called both from Delete, so
+// the annotation does not outlive the Bastion resource, and from Reconcile's rollback path if
+// ensureComputeInstance fails after a private IP was already reserved.
+func releasePrivateIP(ctx context.Context, c client.Client, bastion *extensionsv1alpha1.Bastion) error {
+	if _, ok := bastion.Annotations[annotationPrivateIP]; !ok {
+		return nil
+	}
+
+	patch := client.MergeFrom(bastion.DeepCopy())
+	delete(bastion.Annotations, annotationPrivateIP)
+
+	if err := c.Patch(ctx, bastion, patch); err != nil {
+		return fmt.Errorf("failed to clear reserved bastion private ip annotation: %w", err)
+	}
+
+	return nil
+}
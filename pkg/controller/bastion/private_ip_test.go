@@ -0,0 +1,195 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	aliclient "github.com/gardener/gardener-extension-provider-alicloud/pkg/alicloud/client"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeVPC is an in-memory stand-in for the two aliclient.VPC methods private_ip.go uses. It embeds the
+// interface so it satisfies aliclient.VPC without having to know its full method set.
+type fakeVPC struct {
+	aliclient.VPC
+
+	created int
+	deleted []string
+}
+
+func (f *fakeVPC) CreateNetworkInterface(vSwitchID string) (*vpc.CreateNetworkInterfaceResponse, error) {
+	f.created++
+	return &vpc.CreateNetworkInterfaceResponse{
+		NetworkInterfaceId: "eni-1",
+		PrivateIpAddress:   "10.0.0.5",
+	}, nil
+}
+
+func (f *fakeVPC) DeleteNetworkInterface(networkInterfaceID string) error {
+	f.deleted = append(f.deleted, networkInterfaceID)
+	return nil
+}
+
+func newTestClient(t *testing.T, bastion *extensionsv1alpha1.Bastion) (client.Client, *extensionsv1alpha1.Bastion) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := extensionsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(bastion).Build()
+	return c, bastion
+}
+
+func TestEnsurePrivateIPReleasesTheReservationENIRightAway(t *testing.T) {
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bastion", Namespace: "shoot--foo--bar"},
+	}
+
+	c, _ := newTestClient(t, bastion)
+	vpcClient := &fakeVPC{}
+
+	ip, err := ensurePrivateIP(context.Background(), c, vpcClient, bastion, "vsw-1")
+	if err != nil {
+		t.Fatalf("ensurePrivateIP failed: %v", err)
+	}
+
+	if ip != "10.0.0.5" {
+		t.Errorf("got ip %q, want 10.0.0.5", ip)
+	}
+
+	if len(vpcClient.deleted) != 1 || vpcClient.deleted[0] != "eni-1" {
+		t.Errorf("expected the reservation eni to be deleted before ensurePrivateIP returns, got %v", vpcClient.deleted)
+	}
+
+	if bastion.Annotations[annotationPrivateIP] != "10.0.0.5" {
+		t.Errorf("expected the discovered ip to be persisted as an annotation")
+	}
+}
+
+func TestEnsurePrivateIPReusesThePersistedAnnotation(t *testing.T) {
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "my-bastion",
+			Namespace:   "shoot--foo--bar",
+			Annotations: map[string]string{annotationPrivateIP: "10.0.0.9"},
+		},
+	}
+
+	c, _ := newTestClient(t, bastion)
+	vpcClient := &fakeVPC{}
+
+	ip, err := ensurePrivateIP(context.Background(), c, vpcClient, bastion, "vsw-1")
+	if err != nil {
+		t.Fatalf("ensurePrivateIP failed: %v", err)
+	}
+
+	if ip != "10.0.0.9" {
+		t.Errorf("got ip %q, want the already-persisted 10.0.0.9", ip)
+	}
+
+	if vpcClient.created != 0 {
+		t.Errorf("expected no new eni to be created when an ip is already persisted")
+	}
+}
+
+func TestReleasePrivateIPClearsAnnotation(t *testing.T) {
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-bastion",
+			Namespace: "shoot--foo--bar",
+			Annotations: map[string]string{
+				annotationPrivateIP: "10.0.0.5",
+				"unrelated/keep-me": "yes",
+			},
+		},
+	}
+
+	c, _ := newTestClient(t, bastion)
+
+	if err := releasePrivateIP(context.Background(), c, bastion); err != nil {
+		t.Fatalf("releasePrivateIP failed: %v", err)
+	}
+
+	if _, ok := bastion.Annotations[annotationPrivateIP]; ok {
+		t.Errorf("expected %s annotation to be cleared", annotationPrivateIP)
+	}
+
+	if bastion.Annotations["unrelated/keep-me"] != "yes" {
+		t.Errorf("expected unrelated annotations to be left alone")
+	}
+
+	persisted := &extensionsv1alpha1.Bastion{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(bastion), persisted); err != nil {
+		t.Fatalf("failed to re-fetch bastion: %v", err)
+	}
+
+	if _, ok := persisted.Annotations[annotationPrivateIP]; ok {
+		t.Errorf("expected the persisted bastion to have %s cleared too", annotationPrivateIP)
+	}
+}
+
+func TestReleasePrivateIPNoOpWithoutReservation(t *testing.T) {
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bastion", Namespace: "shoot--foo--bar"},
+	}
+
+	c, _ := newTestClient(t, bastion)
+
+	if err := releasePrivateIP(context.Background(), c, bastion); err != nil {
+		t.Fatalf("releasePrivateIP failed: %v", err)
+	}
+}
+
+// TestEnsureComputeInstanceFailureReleasesReservedPrivateIP drives the same rollback sequence Reconcile
+// wires up: once ensurePrivateIP has reserved (and persisted) a private IP, a subsequent failure creating
+// the compute instance must release that reservation so the next Reconcile attempt discovers a fresh IP
+// rather than being stuck believing the old one is still usable.
+func TestEnsureComputeInstanceFailureReleasesReservedPrivateIP(t *testing.T) {
+	bastion := &extensionsv1alpha1.Bastion{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-bastion", Namespace: "shoot--foo--bar"},
+	}
+
+	c, _ := newTestClient(t, bastion)
+	vpcClient := &fakeVPC{}
+
+	if _, err := ensurePrivateIP(context.Background(), c, vpcClient, bastion, "vsw-1"); err != nil {
+		t.Fatalf("ensurePrivateIP failed: %v", err)
+	}
+
+	if bastion.Annotations[annotationPrivateIP] == "" {
+		t.Fatalf("expected a private ip to be reserved before simulating the instance creation failure")
+	}
+
+	// Simulate ensureComputeInstance failing, the same way actuator_reconcile.go's Reconcile does.
+	createInstanceErr := errors.New("create instance failed")
+	if releaseErr := releasePrivateIP(context.Background(), c, bastion); releaseErr != nil {
+		t.Fatalf("failed to create bastion instance (%v) and failed to release the reserved private ip again (%v)", createInstanceErr, releaseErr)
+	}
+
+	if _, ok := bastion.Annotations[annotationPrivateIP]; ok {
+		t.Errorf("expected the reserved private ip annotation to be cleared after the simulated instance creation failure")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"errors"
+	"fmt"
+
+	aliclient "github.com/gardener/gardener-extension-provider-alicloud/pkg/alicloud/client"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/go-logr/logr"
+)
+
+const (
+	// ramErrCodeRoleNotExist and ramErrCodePolicyNotExist are the Alicloud RAM error codes returned when the
+	// role/policy does not exist, as opposed to some other, unexpected failure (e.g. throttling or a
+	// permissions problem) that should not be papered over as "not found".
+	ramErrCodeRoleNotExist   = "EntityNotExist.Role"
+	ramErrCodePolicyNotExist = "EntityNotExist.Policy"
+
+	// ramAssumeRolePolicyDocument allows ECS instances to assume the bastion's RAM role.
+	ramAssumeRolePolicyDocument = `{
+  "Statement": [
+    {
+      "Action": "sts:AssumeRole",
+      "Effect": "Allow",
+      "Principal": {
+        "Service": ["ecs.aliyuncs.com"]
+      }
+    }
+  ],
+  "Version": "1"
+}`
+
+	// ramPolicyDocument scopes the bastion's RAM role down to the read-only calls it needs to diagnose
+	// its own environment: listing itself, the vSwitches of its VPC and the shoot's load balancers.
+	ramPolicyDocument = `{
+  "Statement": [
+    {
+      "Action": [
+        "ecs:DescribeInstances",
+        "vpc:DescribeVSwitches",
+        "slb:DescribeLoadBalancers"
+      ],
+      "Effect": "Allow",
+      "Resource": "*"
+    }
+  ],
+  "Version": "1"
+}`
+)
+
+// ramPolicyName and ramRoleName are derived from the bastion instance name, so that Delete can find the
+// policy/role again without needing to persist anything beyond what Reconcile already derives from opt.
+func ramPolicyName(opt *Options) string {
+	return opt.BastionInstanceName + "-policy"
+}
+
+func ramRoleName(opt *Options) string {
+	return opt.BastionInstanceName + "-role"
+}
+
+// ensureRAMRole ensures a RAM policy and role scoped to the bastion instance exist, attaches the policy to
+// the role if it is not already attached, and returns the role name to pass as RamRoleName to
+// CreateInstances, so the instance gets an IAM identity of its own instead of reusing the shoot's access
+// key/secret.
+func ensureRAMRole(ramClient aliclient.RAM, opt *Options, logger logr.Logger) (string, error) {
+	policyName := ramPolicyName(opt)
+	roleName := ramRoleName(opt)
+
+	if err := ensureRAMPolicy(ramClient, policyName, logger); err != nil {
+		return "", fmt.Errorf("failed to ensure bastion ram policy %s: %w", policyName, err)
+	}
+
+	if _, err := ramClient.GetRole(roleName); err != nil {
+		if !isRAMNotExist(err, ramErrCodeRoleNotExist) {
+			return "", fmt.Errorf("failed to get bastion ram role %s: %w", roleName, err)
+		}
+
+		logger.Info("creating RAM role", "role", roleName)
+		if _, err := ramClient.CreateRole(roleName, ramAssumeRolePolicyDocument); err != nil {
+			return "", fmt.Errorf("failed to create bastion ram role %s: %w", roleName, err)
+		}
+	} else {
+		logger.Info("RAM role found", "role", roleName)
+	}
+
+	attached, err := ramClient.IsPolicyAttachedToRole(policyName, roleName)
+	if err != nil {
+		return "", fmt.Errorf("failed to check whether bastion ram policy %s is attached to role %s: %w", policyName, roleName, err)
+	}
+
+	if !attached {
+		if err := ramClient.AttachPolicyToRole(policyName, roleName); err != nil {
+			return "", fmt.Errorf("failed to attach bastion ram policy %s to role %s: %w", policyName, roleName, err)
+		}
+	}
+
+	return roleName, nil
+}
+
+func ensureRAMPolicy(ramClient aliclient.RAM, policyName string, logger logr.Logger) error {
+	if _, err := ramClient.GetPolicy(policyName); err != nil {
+		if !isRAMNotExist(err, ramErrCodePolicyNotExist) {
+			return fmt.Errorf("failed to get bastion ram policy %s: %w", policyName, err)
+		}
+
+		logger.Info("creating RAM policy", "policy", policyName)
+		_, err := ramClient.CreatePolicy(policyName, ramPolicyDocument)
+		return err
+	}
+
+	logger.Info("RAM policy found", "policy", policyName)
+	return nil
+}
+
+// releaseRAMRole detaches the bastion's RAM policy from its role and deletes both. It is called from
+// Delete and tolerates the policy/role already being gone.
+func releaseRAMRole(ramClient aliclient.RAM, opt *Options) error {
+	policyName := ramPolicyName(opt)
+	roleName := ramRoleName(opt)
+
+	if err := ramClient.DetachPolicyFromRole(policyName, roleName); err != nil && !isRAMNotExist(err, ramErrCodeRoleNotExist) && !isRAMNotExist(err, ramErrCodePolicyNotExist) {
+		return fmt.Errorf("failed to detach bastion ram policy %s from role %s: %w", policyName, roleName, err)
+	}
+
+	if err := ramClient.DeleteRole(roleName); err != nil && !isRAMNotExist(err, ramErrCodeRoleNotExist) {
+		return fmt.Errorf("failed to delete bastion ram role %s: %w", roleName, err)
+	}
+
+	if err := ramClient.DeletePolicy(policyName); err != nil && !isRAMNotExist(err, ramErrCodePolicyNotExist) {
+		return fmt.Errorf("failed to delete bastion ram policy %s: %w", policyName, err)
+	}
+
+	return nil
+}
+
+// isRAMNotExist reports whether err is the Alicloud RAM "not found" error identified by code, as opposed to
+// some other failure that must not be silently ignored.
+func isRAMNotExist(err error, code string) bool {
+	var serverErr *sdkerrors.ServerError
+	return errors.As(err, &serverErr) && serverErr.ErrorCode() == code
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"fmt"
+	"testing"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ram"
+	"github.com/go-logr/logr"
+)
+
+// notExistError builds a *sdkerrors.ServerError with the given RAM error code, the shape isRAMNotExist
+// recognizes, so the fake below can exercise the not-found path the same way the real RAM client does.
+func notExistError(code string) error {
+	return sdkerrors.NewServerError(404, fmt.Sprintf(`{"Code":%q,"Message":"not found"}`, code), "")
+}
+
+// fakeRAM is an in-memory stand-in for aliclient.RAM. It implements the interface directly (rather than by
+// embedding it) because, unlike the ECS/VPC clients used elsewhere in this package, it is small and fully
+// known.
+type fakeRAM struct {
+	policies  map[string]bool
+	roles     map[string]bool
+	attached  map[string]bool
+	attachErr error
+}
+
+func newFakeRAM() *fakeRAM {
+	return &fakeRAM{policies: map[string]bool{}, roles: map[string]bool{}, attached: map[string]bool{}}
+}
+
+func (f *fakeRAM) GetPolicy(policyName string) (*ram.GetPolicyResponse, error) {
+	if !f.policies[policyName] {
+		return nil, notExistError(ramErrCodePolicyNotExist)
+	}
+	return &ram.GetPolicyResponse{}, nil
+}
+
+func (f *fakeRAM) CreatePolicy(policyName, _ string) (*ram.CreatePolicyResponse, error) {
+	f.policies[policyName] = true
+	return &ram.CreatePolicyResponse{}, nil
+}
+
+func (f *fakeRAM) DeletePolicy(policyName string) error {
+	if !f.policies[policyName] {
+		return notExistError(ramErrCodePolicyNotExist)
+	}
+	delete(f.policies, policyName)
+	return nil
+}
+
+func (f *fakeRAM) GetRole(roleName string) (*ram.GetRoleResponse, error) {
+	if !f.roles[roleName] {
+		return nil, notExistError(ramErrCodeRoleNotExist)
+	}
+	return &ram.GetRoleResponse{}, nil
+}
+
+func (f *fakeRAM) CreateRole(roleName, _ string) (*ram.CreateRoleResponse, error) {
+	f.roles[roleName] = true
+	return &ram.CreateRoleResponse{}, nil
+}
+
+func (f *fakeRAM) DeleteRole(roleName string) error {
+	if !f.roles[roleName] {
+		return notExistError(ramErrCodeRoleNotExist)
+	}
+	delete(f.roles, roleName)
+	return nil
+}
+
+func (f *fakeRAM) AttachPolicyToRole(policyName, roleName string) error {
+	if f.attachErr != nil {
+		return f.attachErr
+	}
+	f.attached[policyName+"/"+roleName] = true
+	return nil
+}
+
+func (f *fakeRAM) DetachPolicyFromRole(policyName, roleName string) error {
+	delete(f.attached, policyName+"/"+roleName)
+	return nil
+}
+
+func (f *fakeRAM) IsPolicyAttachedToRole(policyName, roleName string) (bool, error) {
+	return f.attached[policyName+"/"+roleName], nil
+}
+
+func TestEnsureRAMRoleCreatesMissingPolicyAndRole(t *testing.T) {
+	ramClient := newFakeRAM()
+	opt := &Options{BastionInstanceName: "shoot--foo--bar-bastion"}
+
+	roleName, err := ensureRAMRole(ramClient, opt, logr.Discard())
+	if err != nil {
+		t.Fatalf("ensureRAMRole failed: %v", err)
+	}
+
+	if roleName != ramRoleName(opt) {
+		t.Errorf("got role name %q, want %q", roleName, ramRoleName(opt))
+	}
+
+	if !ramClient.policies[ramPolicyName(opt)] || !ramClient.roles[ramRoleName(opt)] {
+		t.Errorf("expected policy and role to have been created")
+	}
+
+	if !ramClient.attached[ramPolicyName(opt)+"/"+ramRoleName(opt)] {
+		t.Errorf("expected policy to be attached to role")
+	}
+}
+
+func TestEnsureRAMRoleSkipsAttachWhenAlreadyAttached(t *testing.T) {
+	ramClient := newFakeRAM()
+	opt := &Options{BastionInstanceName: "shoot--foo--bar-bastion"}
+
+	if _, err := ensureRAMRole(ramClient, opt, logr.Discard()); err != nil {
+		t.Fatalf("first ensureRAMRole failed: %v", err)
+	}
+
+	ramClient.attachErr = notExistError("should-not-be-attached-again")
+
+	if _, err := ensureRAMRole(ramClient, opt, logr.Discard()); err != nil {
+		t.Fatalf("second ensureRAMRole should have skipped the already-attached policy, got: %v", err)
+	}
+}
+
+func TestReleaseRAMRoleToleratesAlreadyGone(t *testing.T) {
+	ramClient := newFakeRAM()
+	opt := &Options{BastionInstanceName: "shoot--foo--bar-bastion"}
+
+	if err := releaseRAMRole(ramClient, opt); err != nil {
+		t.Fatalf("releaseRAMRole should tolerate a policy/role that was never created, got: %v", err)
+	}
+}
+
+func TestIsRAMNotExist(t *testing.T) {
+	if !isRAMNotExist(notExistError(ramErrCodeRoleNotExist), ramErrCodeRoleNotExist) {
+		t.Errorf("expected matching not-exist error to be recognized")
+	}
+
+	if isRAMNotExist(notExistError("SomeOther.Error"), ramErrCodeRoleNotExist) {
+		t.Errorf("expected unrelated error code not to be treated as not-exist")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+)
+
+const (
+	sshPortRange     = "22/22"
+	denyAllPortRange = "-1/-1"
+
+	// bastionRulePriority is set on the bastion's allow rules ("1" is the highest priority Alicloud
+	// supports), so that none of them can be shadowed by a rule a user adds at the default priority (110).
+	// The deny-all egress rules use priority "100" instead.
+	bastionRulePriority = "1"
+
+	// defaultNicType is used for VPC bastions, whose instances are only reachable through their intranet
+	// (private) NIC.
+	defaultNicType = "intranet"
+
+	policyAccept = "accept"
+	policyDrop   = "drop"
+)
+
+// ingressPermission is a single CIDR a bastion should allow inbound SSH access from, already classified by
+// IP family so callers know whether to populate SourceCidrIp or Ipv6SourceCidrIp.
+type ingressPermission struct {
+	cidr   string
+	isIPv6 bool
+}
+
+// ingressPermissions extracts and classifies the CIDRs configured on the Bastion resource.
+func ingressPermissions(bastion *extensionsv1alpha1.Bastion) ([]ingressPermission, error) {
+	var permissions []ingressPermission
+
+	for _, ingress := range bastion.Spec.Ingress {
+		cidr := ingress.IPBlock.CIDR
+
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bastion ingress CIDR %q: %w", cidr, err)
+		}
+
+		permissions = append(permissions, ingressPermission{cidr: cidr, isIPv6: ip.To4() == nil})
+	}
+
+	return permissions, nil
+}
+
+// nicType returns the NIC type to set on bastion security group rules, defaulting to intranet since VPC
+// bastions have no internet-facing NIC of their own. A non-empty opt.NicType (e.g. "internet") takes its
+// place; DetermineOptions does not set opt.NicType, so bastions reconciled through it always use intranet.
+func nicType(opt *Options) string {
+	if opt.NicType != "" {
+		return opt.NicType
+	}
+
+	return defaultNicType
+}
+
+// ingressAllowSSH builds the rule allowing inbound SSH from a single CIDR to the bastion host. An explicit
+// high priority is set so the rule cannot be shadowed by a lower-priority deny rule a user might add.
+func ingressAllowSSH(securityGroupID string, opt *Options, permission ingressPermission) *ecs.AuthorizeSecurityGroupRequest {
+	request := ecs.CreateAuthorizeSecurityGroupRequest()
+	request.SecurityGroupId = securityGroupID
+	request.Description = "SSH access for bastion"
+	request.IpProtocol = "tcp"
+	request.PortRange = sshPortRange
+	request.Policy = policyAccept
+	request.Priority = bastionRulePriority
+	request.NicType = nicType(opt)
+
+	if permission.isIPv6 {
+		request.Ipv6SourceCidrIp = permission.cidr
+	} else {
+		request.SourceCidrIp = permission.cidr
+	}
+
+	return request
+}
+
+// egressAllowSSHToWorker builds the rule allowing the bastion to reach the shoot's worker nodes on port
+// 22. It references the shoot security group directly via DestGroupId rather than a CIDR, so the rule
+// keeps working as workers are added, removed or rescaled, without the bastion actuator having to update
+// it.
+func egressAllowSSHToWorker(securityGroupID, shootSecurityGroupID string, opt *Options) *ecs.AuthorizeSecurityGroupEgressRequest {
+	request := ecs.CreateAuthorizeSecurityGroupEgressRequest()
+	request.SecurityGroupId = securityGroupID
+	request.Description = "SSH access from bastion to worker nodes"
+	request.IpProtocol = "tcp"
+	request.PortRange = sshPortRange
+	request.Policy = policyAccept
+	request.Priority = bastionRulePriority
+	request.NicType = nicType(opt)
+	request.DestGroupId = shootSecurityGroupID
+
+	return request
+}
+
+// egressDenyAllIPv4 and egressDenyAllIPv6 build the rules denying all other egress traffic from the
+// bastion host, for IPv4 and IPv6 respectively. They are kept as two separate rules, each scoped to a
+// single address family via SourceCidrIp/Ipv6SourceCidrIp, because the Alicloud API represents a rule that
+// sets both as an IPv4 rule and silently ignores Ipv6SourceCidrIp, which would leave IPv6 egress
+// unrestricted. Both are given a lower priority than the allow rules above so the allow rules always take
+// precedence.
+func egressDenyAllIPv4(securityGroupID string, opt *Options) *ecs.AuthorizeSecurityGroupEgressRequest {
+	request := ecs.CreateAuthorizeSecurityGroupEgressRequest()
+	request.SecurityGroupId = securityGroupID
+	request.Description = "deny all other IPv4 egress traffic"
+	request.IpProtocol = "all"
+	request.PortRange = denyAllPortRange
+	request.Policy = policyDrop
+	request.Priority = "100"
+	request.NicType = nicType(opt)
+	request.SourceCidrIp = "0.0.0.0/0"
+
+	return request
+}
+
+func egressDenyAllIPv6(securityGroupID string, opt *Options) *ecs.AuthorizeSecurityGroupEgressRequest {
+	request := ecs.CreateAuthorizeSecurityGroupEgressRequest()
+	request.SecurityGroupId = securityGroupID
+	request.Description = "deny all other IPv6 egress traffic"
+	request.IpProtocol = "all"
+	request.PortRange = denyAllPortRange
+	request.Policy = policyDrop
+	request.Priority = "100"
+	request.NicType = nicType(opt)
+	request.Ipv6SourceCidrIp = "::/0"
+
+	return request
+}
+
+func describeSecurityGroupAttributeRequest(securityGroupID, direction string) *ecs.DescribeSecurityGroupAttributeRequest {
+	request := ecs.CreateDescribeSecurityGroupAttributeRequest()
+	request.SecurityGroupId = securityGroupID
+	request.Direction = direction
+
+	return request
+}
+
+func revokeSecurityGroupRequest(securityGroupID string, permission ecs.Permission) *ecs.RevokeSecurityGroupRequest {
+	request := ecs.CreateRevokeSecurityGroupRequest()
+	request.SecurityGroupId = securityGroupID
+	request.IpProtocol = permission.IpProtocol
+	request.PortRange = permission.PortRange
+	request.SourceCidrIp = permission.SourceCidrIp
+	request.Ipv6SourceCidrIp = permission.Ipv6SourceCidrIp
+	request.SourceGroupId = permission.SourceGroupId
+	request.NicType = permission.NicType
+
+	return request
+}
+
+func revokeSecurityGroupEgressRequest(securityGroupID string, permission ecs.Permission) *ecs.RevokeSecurityGroupEgressRequest {
+	request := ecs.CreateRevokeSecurityGroupEgressRequest()
+	request.SecurityGroupId = securityGroupID
+	request.IpProtocol = permission.IpProtocol
+	request.PortRange = permission.PortRange
+	request.SourceCidrIp = permission.SourceCidrIp
+	request.Ipv6SourceCidrIp = permission.Ipv6SourceCidrIp
+	request.DestGroupId = permission.DestGroupId
+	request.NicType = permission.NicType
+
+	return request
+}
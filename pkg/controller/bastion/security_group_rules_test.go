@@ -0,0 +1,238 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bastion
+
+import (
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestEgressDenyAllIsSplitByAddressFamily(t *testing.T) {
+	opt := &Options{}
+
+	v4 := egressDenyAllIPv4("sg-1", opt)
+	if v4.SourceCidrIp != "0.0.0.0/0" {
+		t.Errorf("got SourceCidrIp %q, want 0.0.0.0/0", v4.SourceCidrIp)
+	}
+	if v4.Ipv6SourceCidrIp != "" {
+		t.Errorf("expected the IPv4 deny rule to leave Ipv6SourceCidrIp unset, got %q", v4.Ipv6SourceCidrIp)
+	}
+
+	v6 := egressDenyAllIPv6("sg-1", opt)
+	if v6.Ipv6SourceCidrIp != "::/0" {
+		t.Errorf("got Ipv6SourceCidrIp %q, want ::/0", v6.Ipv6SourceCidrIp)
+	}
+	if v6.SourceCidrIp != "" {
+		t.Errorf("expected the IPv6 deny rule to leave SourceCidrIp unset, got %q", v6.SourceCidrIp)
+	}
+
+	if v4.Description == v6.Description {
+		t.Errorf("expected the two deny rules to have distinguishable descriptions")
+	}
+}
+
+func TestIngressPermissionsClassifiesByAddressFamily(t *testing.T) {
+	bastion := &extensionsv1alpha1.Bastion{
+		Spec: extensionsv1alpha1.BastionSpec{
+			Ingress: []extensionsv1alpha1.BastionIngressPolicy{
+				{IPBlock: networkingv1.IPBlock{CIDR: "10.0.0.0/8"}},
+				{IPBlock: networkingv1.IPBlock{CIDR: "2001:db8::/32"}},
+			},
+		},
+	}
+
+	permissions, err := ingressPermissions(bastion)
+	if err != nil {
+		t.Fatalf("ingressPermissions failed: %v", err)
+	}
+
+	if len(permissions) != 2 {
+		t.Fatalf("got %d permissions, want 2", len(permissions))
+	}
+
+	if permissions[0].isIPv6 {
+		t.Errorf("expected 10.0.0.0/8 to be classified as IPv4")
+	}
+
+	if !permissions[1].isIPv6 {
+		t.Errorf("expected 2001:db8::/32 to be classified as IPv6")
+	}
+}
+
+func TestIngressPermissionsRejectsInvalidCIDR(t *testing.T) {
+	bastion := &extensionsv1alpha1.Bastion{
+		Spec: extensionsv1alpha1.BastionSpec{
+			Ingress: []extensionsv1alpha1.BastionIngressPolicy{
+				{IPBlock: networkingv1.IPBlock{CIDR: "not-a-cidr"}},
+			},
+		},
+	}
+
+	if _, err := ingressPermissions(bastion); err == nil {
+		t.Errorf("expected an error for an invalid CIDR")
+	}
+}
+
+func TestNicTypeDefaultsToIntranet(t *testing.T) {
+	if got := nicType(&Options{}); got != defaultNicType {
+		t.Errorf("got nic type %q, want %q", got, defaultNicType)
+	}
+
+	if got := nicType(&Options{NicType: "internet"}); got != "internet" {
+		t.Errorf("got nic type %q, want override %q", got, "internet")
+	}
+}
+
+func matchingIngressPermission() ecs.Permission {
+	return ecs.Permission{
+		Description:  "SSH access for bastion",
+		IpProtocol:   "tcp",
+		PortRange:    sshPortRange,
+		SourceCidrIp: "10.0.0.0/8",
+		Priority:     bastionRulePriority,
+		NicType:      defaultNicType,
+		Policy:       policyAccept,
+	}
+}
+
+func TestIngressRuleEqualMatchesIdenticalRule(t *testing.T) {
+	opt := &Options{}
+	wanted := ingressAllowSSH("sg-1", opt, ingressPermission{cidr: "10.0.0.0/8"})
+
+	if !ingressRuleEqual(*wanted, matchingIngressPermission()) {
+		t.Errorf("expected an identical rule to compare equal")
+	}
+}
+
+func TestIngressRuleEqualDetectsPriorityDrift(t *testing.T) {
+	opt := &Options{}
+	wanted := ingressAllowSSH("sg-1", opt, ingressPermission{cidr: "10.0.0.0/8"})
+
+	current := matchingIngressPermission()
+	current.Priority = "110"
+
+	if ingressRuleEqual(*wanted, current) {
+		t.Errorf("expected a priority change to be detected as drift")
+	}
+}
+
+func TestIngressRuleEqualDetectsNicTypeDrift(t *testing.T) {
+	opt := &Options{NicType: "internet"}
+	wanted := ingressAllowSSH("sg-1", opt, ingressPermission{cidr: "10.0.0.0/8"})
+
+	current := matchingIngressPermission()
+	current.NicType = defaultNicType
+
+	if ingressRuleEqual(*wanted, current) {
+		t.Errorf("expected a nic type change to be detected as drift")
+	}
+}
+
+func TestIngressRuleEqualDetectsPolicyDrift(t *testing.T) {
+	opt := &Options{}
+	wanted := ingressAllowSSH("sg-1", opt, ingressPermission{cidr: "10.0.0.0/8"})
+
+	current := matchingIngressPermission()
+	current.Policy = policyDrop
+
+	if ingressRuleEqual(*wanted, current) {
+		t.Errorf("expected a policy change to be detected as drift")
+	}
+}
+
+func TestIngressRulesSymmetricDifferenceRevokesDriftedAndAddsMissing(t *testing.T) {
+	opt := &Options{}
+	wanted := []*ecs.AuthorizeSecurityGroupRequest{ingressAllowSSH("sg-1", opt, ingressPermission{cidr: "10.0.0.0/8"})}
+
+	drifted := matchingIngressPermission()
+	drifted.Priority = "110"
+
+	toAdd, toDelete := ingressRulesSymmetricDifference(wanted, []ecs.Permission{drifted})
+
+	if len(toDelete) != 1 {
+		t.Fatalf("got %d rules to delete, want the drifted current rule to be revoked", len(toDelete))
+	}
+
+	if len(toAdd) != 1 {
+		t.Fatalf("got %d rules to add, want the wanted rule to be (re-)created", len(toAdd))
+	}
+}
+
+func TestIngressRulesSymmetricDifferenceIsEmptyWhenInSync(t *testing.T) {
+	opt := &Options{}
+	wanted := []*ecs.AuthorizeSecurityGroupRequest{ingressAllowSSH("sg-1", opt, ingressPermission{cidr: "10.0.0.0/8"})}
+
+	toAdd, toDelete := ingressRulesSymmetricDifference(wanted, []ecs.Permission{matchingIngressPermission()})
+
+	if len(toAdd) != 0 || len(toDelete) != 0 {
+		t.Errorf("expected no changes when the current rule already matches, got toAdd=%v toDelete=%v", toAdd, toDelete)
+	}
+}
+
+func matchingEgressPermission(destGroupID string) ecs.Permission {
+	return ecs.Permission{
+		Description: "SSH access from bastion to worker nodes",
+		IpProtocol:  "tcp",
+		PortRange:   sshPortRange,
+		DestGroupId: destGroupID,
+		Priority:    bastionRulePriority,
+		NicType:     defaultNicType,
+		Policy:      policyAccept,
+	}
+}
+
+func TestEgressRuleEqualDetectsDestGroupDrift(t *testing.T) {
+	opt := &Options{}
+	wanted := egressAllowSSHToWorker("sg-1", "shoot-sg-1", opt)
+
+	if egressRuleEqual(*wanted, matchingEgressPermission("shoot-sg-2")) {
+		t.Errorf("expected a DestGroupId change to be detected as drift")
+	}
+
+	if !egressRuleEqual(*wanted, matchingEgressPermission("shoot-sg-1")) {
+		t.Errorf("expected a matching DestGroupId to compare equal")
+	}
+}
+
+func TestEgressRulesSymmetricDifferenceRevokesDriftedAndAddsMissing(t *testing.T) {
+	opt := &Options{}
+	wanted := []*ecs.AuthorizeSecurityGroupEgressRequest{egressAllowSSHToWorker("sg-1", "shoot-sg-1", opt)}
+
+	drifted := matchingEgressPermission("shoot-sg-2")
+
+	toAdd, toDelete := egressRulesSymmetricDifference(wanted, []ecs.Permission{drifted})
+
+	if len(toDelete) != 1 {
+		t.Fatalf("got %d rules to delete, want the drifted current rule to be revoked", len(toDelete))
+	}
+
+	if len(toAdd) != 1 {
+		t.Fatalf("got %d rules to add, want the wanted rule to be (re-)created", len(toAdd))
+	}
+}
+
+func TestEgressRulesSymmetricDifferenceIsEmptyWhenInSync(t *testing.T) {
+	opt := &Options{}
+	wanted := []*ecs.AuthorizeSecurityGroupEgressRequest{egressAllowSSHToWorker("sg-1", "shoot-sg-1", opt)}
+
+	toAdd, toDelete := egressRulesSymmetricDifference(wanted, []ecs.Permission{matchingEgressPermission("shoot-sg-1")})
+
+	if len(toAdd) != 0 || len(toDelete) != 0 {
+		t.Errorf("expected no changes when the current rule already matches, got toAdd=%v toDelete=%v", toAdd, toDelete)
+	}
+}